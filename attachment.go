@@ -0,0 +1,128 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+)
+
+// Add a file attachment to this message. The attachment is placed in a
+// multipart/mixed container at the top level of the message, alongside the
+// existing text/html body.
+func (e *Email) AddAttachment(name, contentType string, r io.Reader) error {
+	part := &MIMEPartBinary{
+		Type:        contentType,
+		Disposition: "attachment",
+		Filename:    name,
+		Headers:     textproto.MIMEHeader{},
+		Content:     r,
+	}
+	return e.attachPart(part, false)
+}
+
+// Add an inline attachment (typically an image referenced from the HTML body
+// via cid:<cid>) to this message. Inline attachments are placed in a
+// multipart/related container together with the text/html body.
+func (e *Email) AddInlineAttachment(cid, name, contentType string, r io.Reader) error {
+	part := &MIMEPartBinary{
+		Type:        contentType,
+		Disposition: "inline",
+		Filename:    name,
+		ContentID:   cid,
+		Headers:     textproto.MIMEHeader{},
+		Content:     r,
+	}
+	return e.attachPart(part, true)
+}
+
+// attachPart inserts part into the MIME tree, building the
+// multipart/mixed > multipart/related > multipart/alternative container
+// hierarchy expected by mail clients as inline and non-inline parts are
+// added.
+func (e *Email) attachPart(part MIME, inline bool) error {
+	switch p := e.Message.(type) {
+	case nil:
+		if inline {
+			e.Message = &MIMEMultipart{Type: "multipart/related", Parts: []MIME{part}}
+		} else {
+			e.Message = &MIMEMultipart{Type: "multipart/mixed", Parts: []MIME{part}}
+		}
+	case *MIMEPartText:
+		if inline {
+			e.Message = &MIMEMultipart{Type: "multipart/related", Parts: []MIME{p, part}}
+		} else {
+			e.Message = &MIMEMultipart{Type: "multipart/mixed", Parts: []MIME{p, part}}
+		}
+	case *MIMEMultipart:
+		switch p.Type {
+		case "multipart/alternative":
+			if inline {
+				e.Message = &MIMEMultipart{Type: "multipart/related", Parts: []MIME{p, part}}
+			} else {
+				e.Message = &MIMEMultipart{Type: "multipart/mixed", Parts: []MIME{p, part}}
+			}
+		case "multipart/related":
+			if inline {
+				p.Parts = append(p.Parts, part)
+			} else {
+				e.Message = &MIMEMultipart{Type: "multipart/mixed", Parts: []MIME{p, part}}
+			}
+		case "multipart/mixed":
+			if inline {
+				if len(p.Parts) == 0 {
+					p.Parts = append(p.Parts, &MIMEMultipart{Type: "multipart/related", Parts: []MIME{part}})
+				} else if related, ok := p.Parts[0].(*MIMEMultipart); ok && related.Type == "multipart/related" {
+					related.Parts = append(related.Parts, part)
+				} else {
+					p.Parts[0] = &MIMEMultipart{Type: "multipart/related", Parts: []MIME{p.Parts[0], part}}
+				}
+			} else {
+				p.Parts = append(p.Parts, part)
+			}
+		default:
+			return ErrInvalidMimeTree
+		}
+	default:
+		return ErrInvalidMimeTree
+	}
+	return nil
+}
+
+// encodeContentDisposition renders a Content-Disposition value for the given
+// disposition and (optional) filename, falling back to the RFC 2231
+// extended-parameter encoding when the filename is not plain ASCII.
+func encodeContentDisposition(disposition, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+	return fmt.Sprintf("%s; %s", disposition, encodeRFC2231Filename(filename))
+}
+
+func encodeRFC2231Filename(filename string) string {
+	needsEncoding := false
+	for i := 0; i < len(filename); i++ {
+		c := filename[i]
+		if c < 0x20 || c > 0x7e || c == '%' || c == '"' {
+			needsEncoding = true
+			break
+		}
+	}
+	if !needsEncoding {
+		return fmt.Sprintf(`filename="%s"`, filename)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("filename*=UTF-8''")
+	for i := 0; i < len(filename); i++ {
+		c := filename[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
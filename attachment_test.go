@@ -0,0 +1,146 @@
+package email
+
+import (
+	"bytes"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestAddAttachment(t *testing.T) {
+	m := New("A test subject", mail.Address{"Test", "test@example.org"}, mail.Address{"To", "to@example.org"})
+	m.AddTextBodyString("Hello")
+	m.AddHTMLBody(strings.NewReader("<p>Hello</p>"))
+
+	if err := m.AddInlineAttachment("logo@example.org", "logo.png", "image/png", strings.NewReader("fake-png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddAttachment("report.pdf", "application/pdf", strings.NewReader("fake-pdf")); err != nil {
+		t.Fatal(err)
+	}
+
+	mixed, ok := m.Message.(*MIMEMultipart)
+	if !ok || mixed.Type != "multipart/mixed" {
+		t.Fatalf("expected top-level multipart/mixed, got %#v", m.Message)
+	}
+	if len(mixed.Parts) != 2 {
+		t.Fatalf("expected 2 parts in multipart/mixed, got %d", len(mixed.Parts))
+	}
+
+	related, ok := mixed.Parts[0].(*MIMEMultipart)
+	if !ok || related.Type != "multipart/related" {
+		t.Fatalf("expected multipart/related as first part, got %#v", mixed.Parts[0])
+	}
+
+	alt, ok := related.Parts[0].(*MIMEMultipart)
+	if !ok || alt.Type != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative inside multipart/related, got %#v", related.Parts[0])
+	}
+
+	b, err := m.Bytes(Mode8Bit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mess, err := mail.ReadMessage(bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(mess.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("expected multipart Content-Type, got %s (%v)", mediaType, err)
+	}
+
+	var filenames, contentIDs []string
+	var walk func(r *multipart.Reader)
+	walk = func(r *multipart.Reader) {
+		for {
+			part, err := r.NextPart()
+			if err != nil {
+				return
+			}
+			if fn := part.FileName(); fn != "" {
+				filenames = append(filenames, fn)
+			}
+			if cid := part.Header.Get("Content-ID"); cid != "" {
+				contentIDs = append(contentIDs, cid)
+			}
+			if sub, subParams, err := mime.ParseMediaType(part.Header.Get("Content-Type")); err == nil && strings.HasPrefix(sub, "multipart/") {
+				walk(multipart.NewReader(part, subParams["boundary"]))
+			}
+		}
+	}
+	walk(multipart.NewReader(mess.Body, params["boundary"]))
+
+	if len(filenames) != 2 || filenames[0] != "logo.png" || filenames[1] != "report.pdf" {
+		t.Errorf("expected filenames [logo.png report.pdf], got %v", filenames)
+	}
+	if len(contentIDs) != 1 || contentIDs[0] != "<logo@example.org>" {
+		t.Errorf("expected Content-ID [<logo@example.org>], got %v", contentIDs)
+	}
+}
+
+// TestAddAttachmentBeforeBody checks that attaching a file before the
+// text/html body still builds the multipart/mixed > multipart/alternative
+// hierarchy, rather than leaving the attachment sandwiched between the body
+// parts with no alternative wrapping.
+func TestAddAttachmentBeforeBody(t *testing.T) {
+	m := New("A test subject", mail.Address{"Test", "test@example.org"}, mail.Address{"To", "to@example.org"})
+
+	if err := m.AddAttachment("report.pdf", "application/pdf", strings.NewReader("fake-pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddTextBodyString("Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddHTMLBody(strings.NewReader("<p>Hello</p>")); err != nil {
+		t.Fatal(err)
+	}
+
+	mixed, ok := m.Message.(*MIMEMultipart)
+	if !ok || mixed.Type != "multipart/mixed" {
+		t.Fatalf("expected top-level multipart/mixed, got %#v", m.Message)
+	}
+	if len(mixed.Parts) != 2 {
+		t.Fatalf("expected 2 parts in multipart/mixed, got %d", len(mixed.Parts))
+	}
+
+	alt, ok := mixed.Parts[0].(*MIMEMultipart)
+	if !ok || alt.Type != "multipart/alternative" || len(alt.Parts) != 2 {
+		t.Fatalf("expected multipart/alternative with 2 parts as first part, got %#v", mixed.Parts[0])
+	}
+	if _, ok := alt.Parts[0].(*MIMEPartText); !ok {
+		t.Errorf("expected text part first in multipart/alternative, got %#v", alt.Parts[0])
+	}
+	if _, ok := alt.Parts[1].(*MIMEPartText); !ok {
+		t.Errorf("expected html part second in multipart/alternative, got %#v", alt.Parts[1])
+	}
+
+	attachment, ok := mixed.Parts[1].(*MIMEPartBinary)
+	if !ok || attachment.Filename != "report.pdf" {
+		t.Fatalf("expected report.pdf attachment as second part, got %#v", mixed.Parts[1])
+	}
+
+	if _, err := m.Bytes(Mode8Bit); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeRFC2231Filename(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"report.pdf", `filename="report.pdf"`},
+		{"résumé.pdf", "filename*=UTF-8''r%C3%A9sum%C3%A9.pdf"},
+	}
+
+	for _, test := range tests {
+		if o := encodeRFC2231Filename(test.input); o != test.output {
+			t.Errorf("encodeRFC2231Filename(%q) = %q, want %q", test.input, o, test.output)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// headers that are derived from Email fields or the MIME tree, and must not
+// be copied verbatim into Email.Headers when parsing.
+var emlStructuredHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+	"Content-Disposition":       true,
+	"Content-Id":                true,
+}
+
+// Parse an RFC 5322 message (as produced by this package or a common MUA)
+// into an *Email. Unknown headers are preserved on Email.Headers so that a
+// subsequent WriteTo(w, Mode8Bit) reproduces an equivalent message.
+func ParseEML(r io.Reader) (*Email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Email{Headers: textproto.MIMEHeader{}}
+	dec := new(mime.WordDecoder)
+
+	if from := msg.Header.Get("From"); from != "" {
+		addr, err := mail.ParseAddress(from)
+		if err != nil {
+			return nil, fmt.Errorf("email: parsing From: %w", err)
+		}
+		e.From = *addr
+	}
+
+	for _, h := range []struct {
+		key string
+		dst *[]mail.Address
+	}{
+		{"To", &e.To},
+		{"Cc", &e.Cc},
+		{"Bcc", &e.Bcc},
+		{"Reply-To", &e.ReplyTo},
+	} {
+		if v := msg.Header.Get(h.key); v != "" {
+			addrs, err := mail.ParseAddressList(v)
+			if err != nil {
+				return nil, fmt.Errorf("email: parsing %s: %w", h.key, err)
+			}
+			for _, a := range addrs {
+				*h.dst = append(*h.dst, *a)
+			}
+		}
+	}
+
+	if subject := msg.Header.Get("Subject"); subject != "" {
+		decoded, err := dec.DecodeHeader(subject)
+		if err != nil {
+			decoded = subject
+		}
+		e.Subject = decoded
+	}
+
+	if date, err := msg.Header.Date(); err == nil {
+		e.Date = date
+	}
+
+	e.MessageId = msg.Header.Get("Message-Id")
+
+	for key, values := range msg.Header {
+		ck := textproto.CanonicalMIMEHeaderKey(key)
+		if emlStructuredHeaders[ck] {
+			continue
+		}
+		for _, v := range values {
+			e.Headers.Add(ck, v)
+		}
+	}
+
+	message, err := parseEMLPart(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	e.Message = message
+
+	return e, nil
+}
+
+// Parse an RFC 5322 message from a byte slice. See ParseEML.
+func ParseEMLBytes(b []byte) (*Email, error) {
+	return ParseEML(bytes.NewReader(b))
+}
+
+// Parse an RFC 5322 message from a string. See ParseEML.
+func ParseEMLString(s string) (*Email, error) {
+	return ParseEML(strings.NewReader(s))
+}
+
+// parseEMLPart recursively turns a MIME part into a MIMEPartText,
+// MIMEPartBinary or MIMEMultipart, depending on its Content-Type.
+func parseEMLPart(header textproto.MIMEHeader, body io.Reader) (MIME, error) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain; charset=us-ascii"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("email: parsing Content-Type %q: %w", contentType, err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return parseEMLMultipart(header, mediaType, params, body)
+	}
+
+	decoded, err := decodeEMLBody(header.Get("Content-Transfer-Encoding"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	disposition, dispositionParams := parseEMLDisposition(header.Get("Content-Disposition"))
+
+	if strings.HasPrefix(mediaType, "text/") {
+		charset := params["charset"]
+		if charset == "" {
+			charset = "utf-8"
+		}
+		return &MIMEPartText{
+			Type:        mediaType,
+			Disposition: disposition,
+			Charset:     charset,
+			Headers:     textproto.MIMEHeader{},
+			Content:     bytes.NewBuffer(decoded),
+		}, nil
+	}
+
+	return &MIMEPartBinary{
+		Type:        mediaType,
+		Disposition: disposition,
+		Filename:    dispositionParams["filename"],
+		ContentID:   strings.Trim(header.Get("Content-Id"), "<>"),
+		Headers:     textproto.MIMEHeader{},
+		Content:     bytes.NewReader(decoded),
+	}, nil
+}
+
+func parseEMLMultipart(header textproto.MIMEHeader, mediaType string, params map[string]string, body io.Reader) (MIME, error) {
+	boundary := params["boundary"]
+	mr := multipart.NewReader(body, boundary)
+
+	m := &MIMEMultipart{
+		Type:     mediaType,
+		Boundary: boundary,
+		Headers:  textproto.MIMEHeader{},
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := parseEMLPart(part.Header, part)
+		if err != nil {
+			return nil, err
+		}
+		m.Parts = append(m.Parts, sub)
+	}
+
+	return m, nil
+}
+
+func parseEMLDisposition(header string) (string, map[string]string) {
+	if header == "" {
+		return "inline", nil
+	}
+	disposition, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "inline", nil
+	}
+	return disposition, params
+}
+
+func decodeEMLBody(transferEncoding string, body io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(body))
+	case "base64":
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, body))
+	case "7bit", "8bit", "binary", "":
+		return io.ReadAll(body)
+	default:
+		return io.ReadAll(body)
+	}
+}
@@ -0,0 +1,177 @@
+package email
+
+import (
+	"io"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestParseEMLRoundTrip(t *testing.T) {
+	m := New("A test subject", mail.Address{"Test", "test@example.org"}, mail.Address{"To", "to@example.org"})
+	m.AddCc(mail.Address{"CC1", "cc1@example.org"})
+	m.AddHeader("X-Custom", "value")
+	m.AddTextBodyString("Hello, world!")
+	m.AddHTMLBody(strings.NewReader("<p>Hello, world!</p>"))
+
+	b, err := m.Bytes(Mode8Bit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseEMLBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Subject != m.Subject {
+		t.Errorf("Subject = %q, want %q", parsed.Subject, m.Subject)
+	}
+	if parsed.From.Address != m.From.Address {
+		t.Errorf("From = %q, want %q", parsed.From.Address, m.From.Address)
+	}
+	if len(parsed.To) != 1 || parsed.To[0].Address != "to@example.org" {
+		t.Errorf("To = %v, want [to@example.org]", parsed.To)
+	}
+	if len(parsed.Cc) != 1 || parsed.Cc[0].Address != "cc1@example.org" {
+		t.Errorf("Cc = %v, want [cc1@example.org]", parsed.Cc)
+	}
+	if parsed.Headers.Get("X-Custom") != "value" {
+		t.Errorf("X-Custom = %q, want %q", parsed.Headers.Get("X-Custom"), "value")
+	}
+
+	alt, ok := parsed.Message.(*MIMEMultipart)
+	if !ok || alt.Type != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got %#v", parsed.Message)
+	}
+	if len(alt.Parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(alt.Parts))
+	}
+
+	text, ok := alt.Parts[0].(*MIMEPartText)
+	if !ok || strings.TrimRight(text.Content.String(), "\r\n") != "Hello, world!" {
+		t.Errorf("text part = %#v", alt.Parts[0])
+	}
+	html, ok := alt.Parts[1].(*MIMEPartText)
+	if !ok || strings.TrimRight(html.Content.String(), "\r\n") != "<p>Hello, world!</p>" {
+		t.Errorf("html part = %#v", alt.Parts[1])
+	}
+}
+
+// TestParseEMLThunderbirdFixture parses a message shaped like a real MUA's
+// output (mixed > related > alternative, RFC 2047-encoded non-ASCII From
+// name, base64 and quoted-printable bodies) rather than one produced by this
+// package's own writer.
+func TestParseEMLThunderbirdFixture(t *testing.T) {
+	const fixture = "From: =?UTF-8?Q?J=C3=B6rg_Schmidt?= <jorg@example.org>\r\n" +
+		"To: Jane Doe <jane@example.org>\r\n" +
+		"Subject: Quarterly report\r\n" +
+		"Date: Tue, 03 Feb 2026 09:15:00 +0100\r\n" +
+		"Message-ID: <thunderbird-1@example.org>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"mixedBoundary\"\r\n" +
+		"\r\n" +
+		"--mixedBoundary\r\n" +
+		"Content-Type: multipart/related; boundary=\"relatedBoundary\"\r\n" +
+		"\r\n" +
+		"--relatedBoundary\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"altBoundary\"\r\n" +
+		"\r\n" +
+		"--altBoundary\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Please see the attached chart.\r\n" +
+		"--altBoundary\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"<p>Please see the attached chart: <img src=3D\"cid:chart1\"></p>\r\n" +
+		"--altBoundary--\r\n" +
+		"--relatedBoundary\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-ID: <chart1>\r\n" +
+		"Content-Disposition: inline; filename=\"chart.png\"\r\n" +
+		"\r\n" +
+		"ZmFrZS1wbmc=\r\n" +
+		"--relatedBoundary--\r\n" +
+		"--mixedBoundary\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"report.pdf\"\r\n" +
+		"\r\n" +
+		"ZmFrZS1wZGY=\r\n" +
+		"--mixedBoundary--\r\n"
+
+	parsed, err := ParseEMLString(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Subject != "Quarterly report" {
+		t.Errorf("Subject = %q", parsed.Subject)
+	}
+	if parsed.From.Name != "Jörg Schmidt" {
+		t.Errorf("From.Name = %q, want %q", parsed.From.Name, "Jörg Schmidt")
+	}
+
+	mixed, ok := parsed.Message.(*MIMEMultipart)
+	if !ok || mixed.Type != "multipart/mixed" || len(mixed.Parts) != 2 {
+		t.Fatalf("expected top-level multipart/mixed with 2 parts, got %#v", parsed.Message)
+	}
+
+	related, ok := mixed.Parts[0].(*MIMEMultipart)
+	if !ok || related.Type != "multipart/related" || len(related.Parts) != 2 {
+		t.Fatalf("expected multipart/related with 2 parts, got %#v", mixed.Parts[0])
+	}
+
+	alt, ok := related.Parts[0].(*MIMEMultipart)
+	if !ok || alt.Type != "multipart/alternative" || len(alt.Parts) != 2 {
+		t.Fatalf("expected multipart/alternative with 2 parts, got %#v", related.Parts[0])
+	}
+
+	text, ok := alt.Parts[0].(*MIMEPartText)
+	if !ok || strings.TrimRight(text.Content.String(), "\r\n") != "Please see the attached chart." {
+		t.Errorf("text part = %#v", alt.Parts[0])
+	}
+
+	image, ok := related.Parts[1].(*MIMEPartBinary)
+	if !ok || image.Type != "image/png" || image.Filename != "chart.png" || image.ContentID != "chart1" {
+		t.Fatalf("unexpected inline image part: %#v", related.Parts[1])
+	}
+	imageBytes, err := io.ReadAll(image.Content)
+	if err != nil || string(imageBytes) != "fake-png" {
+		t.Errorf("inline image content = %q, %v", imageBytes, err)
+	}
+
+	attachment, ok := mixed.Parts[1].(*MIMEPartBinary)
+	if !ok || attachment.Type != "application/pdf" || attachment.Filename != "report.pdf" {
+		t.Fatalf("unexpected attachment part: %#v", mixed.Parts[1])
+	}
+}
+
+func TestParseEMLEncodedSubject(t *testing.T) {
+	raw := "From: \"Test\" <test@example.org>\r\n" +
+		"To: \"To\" <to@example.org>\r\n" +
+		"Subject: =?utf-8?q?=E6=B5=8B=E8=AF=95?=\r\n" +
+		"Date: Mon, 02 Jan 2006 15:04:05 -0700\r\n" +
+		"Message-Id: <abc@example.org>\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Hello\r\n"
+
+	parsed, err := ParseEMLString(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Subject != "测试" {
+		t.Errorf("Subject = %q, want %q", parsed.Subject, "测试")
+	}
+
+	text, ok := parsed.Message.(*MIMEPartText)
+	if !ok || strings.TrimRight(text.Content.String(), "\r\n") != "Hello" {
+		t.Errorf("body = %#v", parsed.Message)
+	}
+}
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxBoundaryCollisionRetries bounds how many times WriteTo will regenerate
+// a MIMEMultipart's boundary if it is found inside one of its own parts.
+const maxBoundaryCollisionRetries = 5
+
+// NewRandomMessageID returns a cryptographically random Message-Id of the
+// form "<base32(rand[16])@domain>". If domain is empty, the local
+// hostname is used, falling back to a random label if that is unavailable.
+func NewRandomMessageID(domain string) string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+
+	if domain == "" {
+		domain = localDomain()
+	}
+
+	id := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf[:]))
+	return fmt.Sprintf("<%s@%s>", id, domain)
+}
+
+// NewRandomBoundary returns a cryptographically random multipart boundary:
+// a 30-byte base64url-encoded string prefixed with "----=_Part_" so that it
+// cannot plausibly appear in ordinary message bodies.
+func NewRandomBoundary() string {
+	var buf [30]byte
+	_, _ = rand.Read(buf[:])
+	return "----=_Part_" + base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString(buf[:])
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return ""
+}
+
+func localDomain() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf[:])) + ".invalid"
+}
+
+// boundaryCollides reports whether p.Boundary appears inside the unencoded
+// Content of one of p's direct, already-buffered text parts. Streamed binary
+// parts are not scanned, since that would force buffering them into memory,
+// and neither are the parts of nested multiparts.
+func boundaryCollides(p *MIMEMultipart) bool {
+	marker := []byte(p.Boundary)
+	for _, part := range p.Parts {
+		if t, ok := part.(*MIMEPartText); ok && bytes.Contains(t.Content.Bytes(), marker) {
+			return true
+		}
+	}
+	return false
+}
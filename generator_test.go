@@ -0,0 +1,54 @@
+package email
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewRandomMessageIDUnique(t *testing.T) {
+	a := NewRandomMessageID("example.org")
+	b := NewRandomMessageID("example.org")
+
+	if a == b {
+		t.Errorf("expected distinct message ids, got %q twice", a)
+	}
+	if !strings.HasPrefix(a, "<") || !strings.HasSuffix(a, "@example.org>") {
+		t.Errorf("unexpected message id format: %q", a)
+	}
+}
+
+func TestNewRandomBoundaryUnique(t *testing.T) {
+	a := NewRandomBoundary()
+	b := NewRandomBoundary()
+
+	if a == b {
+		t.Errorf("expected distinct boundaries, got %q twice", a)
+	}
+	if !strings.HasPrefix(a, "----=_Part_") {
+		t.Errorf("unexpected boundary format: %q", a)
+	}
+}
+
+func TestBoundaryCollisionRegenerated(t *testing.T) {
+	textPart := &MIMEPartText{
+		Type:    "text/plain",
+		Content: bytes.NewBufferString("contains ----=_Part_collide"),
+	}
+	p := &MIMEMultipart{
+		Type:     "multipart/mixed",
+		Boundary: "----=_Part_collide",
+		Parts:    []MIME{textPart},
+	}
+
+	if !boundaryCollides(p) {
+		t.Fatal("expected a collision to be detected")
+	}
+
+	if err := p.WriteTo(&bytes.Buffer{}, Mode8Bit); err != nil {
+		t.Fatal(err)
+	}
+	if p.Boundary == "----=_Part_collide" {
+		t.Error("expected the colliding boundary to be regenerated")
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"net/textproto"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/openpgp"
 )
 
 // The mode determines the maximum output encoding, 7Bit, 8Bit or Binary.
@@ -47,12 +49,12 @@ var (
 
 // Function to generate a boundary for a multipart message
 var BoundaryGenerator = func(p *MIMEMultipart) string {
-	return "boundary"
+	return NewRandomBoundary()
 }
 
 // Function to generate message ids
-var MessageIDGenerator = func(p *Email) string {
-	return "messageid"
+var MessageIDGenerator = func(e *Email) string {
+	return NewRandomMessageID(domainOf(e.From.Address))
 }
 
 // An email contains all field needing for constructing the message. The actual
@@ -73,6 +75,38 @@ type Email struct {
 
 	// Actual message
 	Message MIME
+
+	// Middlewares run, in order, after Date/MessageId/Headers defaults are
+	// filled but before the message is serialized. See Use.
+	Middlewares []Middleware
+
+	// PGPType selects whether WriteTo wraps Message in a PGP/MIME
+	// signed or encrypted envelope. Set via SignPGP / EncryptPGP.
+	PGPType PGPType
+
+	pgpSigner     *openpgp.Entity
+	pgpRecipients openpgp.EntityList
+}
+
+// Middleware inspects or mutates an Email before it is serialized. Handle
+// receives the Mode that WriteTo will actually serialize with, so a
+// middleware that needs to hash or sign the wire bytes (e.g. DKIMSigner)
+// sees the same encoding the recipient will. See Email.Use.
+type Middleware interface {
+	Handle(e *Email, m Mode) error
+}
+
+// MiddlewareFunc adapts a plain function to the Middleware interface.
+type MiddlewareFunc func(e *Email, m Mode) error
+
+func (f MiddlewareFunc) Handle(e *Email, m Mode) error {
+	return f(e, m)
+}
+
+// Register middlewares to run on this email before it is serialized by
+// WriteTo. Middlewares run in the order they were added.
+func (e *Email) Use(m ...Middleware) {
+	e.Middlewares = append(e.Middlewares, m...)
 }
 
 type MIME interface {
@@ -184,7 +218,18 @@ func writeEscapeAddressHeader(b *bytes.Buffer, key string, addresses ...mail.Add
 	b.Write(line)
 }
 
-func writeBoundary(w io.Writer, boundary string) error {
+// writeBoundaryDelim writes an RFC 2046 delimiter, separating the preceding
+// part (if any) from the one that follows.
+func writeBoundaryDelim(w io.Writer, boundary string) error {
+	if _, err := w.Write([]byte(lineEnd + "--" + boundary + lineEnd)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeBoundaryClose writes the RFC 2046 close-delimiter that terminates a
+// multipart body after its last part.
+func writeBoundaryClose(w io.Writer, boundary string) error {
 	if _, err := w.Write([]byte(lineEnd + "--" + boundary + "--" + lineEnd)); err != nil {
 		return err
 	}
@@ -215,6 +260,12 @@ func (e *Email) WriteTo(w io.Writer, m Mode) error {
 		e.Headers = make(textproto.MIMEHeader)
 	}
 
+	for _, mw := range e.Middlewares {
+		if err := mw.Handle(e, m); err != nil {
+			return err
+		}
+	}
+
 	writeEscapeHeader(buf, "Date", e.Date.Format(time.RFC1123Z))
 	writeEscapeAddressHeader(buf, "From", e.From)
 
@@ -241,10 +292,15 @@ func (e *Email) WriteTo(w io.Writer, m Mode) error {
 		}
 	}
 
+	message, err := e.pgpWrap(m)
+	if err != nil {
+		return err
+	}
+
 	if _, err := io.Copy(w, buf); err != nil {
 		return err
 	}
-	return e.Message.WriteTo(w, m)
+	return message.WriteTo(w, m)
 }
 
 // Add a header to the message. These headers are not validated, and headers
@@ -257,6 +313,68 @@ func (e *Email) AddHeader(key, value string) error {
 	return nil
 }
 
+// SetHeader sets a header on the message, replacing any existing values.
+// Middlewares should prefer this over AddHeader so that running the same
+// Middleware chain again (e.g. WriteTo called more than once on the same
+// Email) is idempotent rather than duplicating headers.
+func (e *Email) SetHeader(key, value string) {
+	if e.Headers == nil {
+		e.Headers = make(textproto.MIMEHeader)
+	}
+	e.Headers.Set(key, value)
+}
+
+// insertBodyPart inserts a text/html body part into the MIME tree, drilling
+// through any multipart/mixed > multipart/related hierarchy built by
+// AddAttachment/AddInlineAttachment - the same way attachPart drills through
+// it from the other direction - to reach or create the multipart/alternative
+// body. prepend controls whether part goes before or after any existing body
+// part (AddTextBody puts plain text first, AddHTMLBody last).
+func insertBodyPart(existing MIME, part MIME, prepend bool) (MIME, error) {
+	switch p := existing.(type) {
+	case nil:
+		return part, nil
+	case *MIMEPartText:
+		if prepend {
+			return &MIMEMultipart{Type: "multipart/alternative", Parts: []MIME{part, p}}, nil
+		}
+		return &MIMEMultipart{Type: "multipart/alternative", Parts: []MIME{p, part}}, nil
+	case *MIMEMultipart:
+		switch p.Type {
+		case "multipart/alternative":
+			if prepend {
+				p.Parts = append([]MIME{part}, p.Parts...)
+			} else {
+				p.Parts = append(p.Parts, part)
+			}
+			return p, nil
+		case "multipart/related", "multipart/mixed":
+			if len(p.Parts) == 0 {
+				p.Parts = []MIME{part}
+				return p, nil
+			}
+			switch p.Parts[0].(type) {
+			case *MIMEPartText, *MIMEMultipart:
+				body, err := insertBodyPart(p.Parts[0], part, prepend)
+				if err != nil {
+					return nil, err
+				}
+				p.Parts[0] = body
+			default:
+				// The first part is an attachment added before any body
+				// existed (e.g. AddAttachment called first); the body
+				// becomes the new first part.
+				p.Parts = append([]MIME{part}, p.Parts...)
+			}
+			return p, nil
+		default:
+			return nil, ErrInvalidMimeTree
+		}
+	default:
+		return nil, ErrInvalidMimeTree
+	}
+}
+
 // Add a text body to this message. The text must be UTF-8. Adding multiple text
 // bodies is not recommended, but will not throw an error.
 func (e *Email) AddTextBody(r io.Reader) error {
@@ -269,19 +387,11 @@ func (e *Email) AddTextBody(r io.Reader) error {
 		Content:     buffer,
 	}
 
-	switch p := e.Message.(type) {
-	case nil:
-		e.Message = textPart
-	case *MIMEMultipart:
-		p.Parts = append([]MIME{textPart}, p.Parts...)
-	case *MIMEPartText:
-		e.Message = &MIMEMultipart{
-			Type:  "multipart/alternative",
-			Parts: []MIME{textPart, p},
-		}
-	default:
-		return ErrInvalidMimeTree
+	message, err := insertBodyPart(e.Message, textPart, true)
+	if err != nil {
+		return err
 	}
+	e.Message = message
 	return nil
 }
 
@@ -301,19 +411,11 @@ func (e *Email) AddHTMLBody(r io.Reader) error {
 		Content:     buffer,
 	}
 
-	switch p := e.Message.(type) {
-	case nil:
-		e.Message = htmlPart
-	case *MIMEMultipart:
-		p.Parts = append(p.Parts, htmlPart)
-	case *MIMEPartText:
-		e.Message = &MIMEMultipart{
-			Type:  "multipart/alternative",
-			Parts: []MIME{p, htmlPart},
-		}
-	default:
-		return ErrInvalidMimeTree
+	message, err := insertBodyPart(e.Message, htmlPart, false)
+	if err != nil {
+		return err
 	}
+	e.Message = message
 	return nil
 }
 
@@ -328,6 +430,8 @@ type MIMEPartText struct {
 type MIMEPartBinary struct {
 	Type        string
 	Disposition string
+	Filename    string
+	ContentID   string
 	Headers     textproto.MIMEHeader
 	Content     io.Reader
 }
@@ -508,6 +612,12 @@ func (p *MIMEPartBinary) WriteTo(w io.Writer, m Mode) error {
 
 	writeEscapeHeader(headerBuf, "Content-Type", fmt.Sprintf("%s", p.Type))
 	writeEscapeHeader(headerBuf, "Content-Transfer-Encoding", contentEncoding)
+	if p.Disposition != "" {
+		writeEscapeHeader(headerBuf, "Content-Disposition", encodeContentDisposition(p.Disposition, p.Filename))
+	}
+	if p.ContentID != "" {
+		writeEscapeHeader(headerBuf, "Content-ID", fmt.Sprintf("<%s>", p.ContentID))
+	}
 
 	headerBuf.WriteString(lineEnd)
 
@@ -541,6 +651,9 @@ func (p *MIMEMultipart) WriteTo(w io.Writer, m Mode) error {
 	if p.Boundary == "" {
 		p.Boundary = BoundaryGenerator(p)
 	}
+	for i := 0; i < maxBoundaryCollisionRetries && boundaryCollides(p); i++ {
+		p.Boundary = BoundaryGenerator(p)
+	}
 
 	writeEscapeHeader(buf, "Content-Type", fmt.Sprintf("%s; boundary=\"%s\"", p.Type, p.Boundary))
 
@@ -550,14 +663,24 @@ func (p *MIMEMultipart) WriteTo(w io.Writer, m Mode) error {
 		return err
 	}
 
-	if err := writeBoundary(w, p.Boundary); err != nil {
+	if len(p.Parts) == 0 {
+		return writeBoundaryClose(w, p.Boundary)
+	}
+
+	if err := writeBoundaryDelim(w, p.Boundary); err != nil {
 		return err
 	}
 
-	for _, part := range p.Parts {
+	for i, part := range p.Parts {
 		if err := part.WriteTo(w, m); err != nil {
 			return err
-		} else if err = writeBoundary(w, p.Boundary); err != nil {
+		}
+
+		if i == len(p.Parts)-1 {
+			if err := writeBoundaryClose(w, p.Boundary); err != nil {
+				return err
+			}
+		} else if err := writeBoundaryDelim(w, p.Boundary); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,93 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ListUnsubscribe returns a middleware that adds a List-Unsubscribe header
+// pointing at url (a "mailto:" or "https:" URI) together with
+// List-Unsubscribe-Post, so compliant mail clients offer a one-click
+// unsubscribe action.
+func ListUnsubscribe(url string) Middleware {
+	return MiddlewareFunc(func(e *Email, _ Mode) error {
+		e.SetHeader("List-Unsubscribe", fmt.Sprintf("<%s>", url))
+		e.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+		return nil
+	})
+}
+
+// Threading returns a middleware that adds In-Reply-To and References
+// headers, linking this email to an earlier message in the same thread.
+// inReplyTo and the entries in references are message ids without the
+// surrounding angle brackets.
+func Threading(inReplyTo string, references ...string) Middleware {
+	return MiddlewareFunc(func(e *Email, _ Mode) error {
+		if inReplyTo != "" {
+			e.SetHeader("In-Reply-To", fmt.Sprintf("<%s>", inReplyTo))
+		}
+		if len(references) > 0 {
+			wrapped := make([]string, len(references))
+			for i, r := range references {
+				wrapped[i] = fmt.Sprintf("<%s>", r)
+			}
+			e.SetHeader("References", strings.Join(wrapped, " "))
+		}
+		return nil
+	})
+}
+
+// ReceivedStamp returns a middleware that sets a Received header
+// recording the handoff to the given relay, as if by was the server about
+// to accept the message for delivery. The header is stored in Headers, so
+// WriteTo emits it after Date, From, To, Subject and the other standard
+// headers, not at the top of the header block.
+func ReceivedStamp(by string) Middleware {
+	return MiddlewareFunc(func(e *Email, _ Mode) error {
+		e.SetHeader("Received", fmt.Sprintf("by %s with ESMTPA id %s; %s",
+			by, e.MessageId, e.Date.Format(time.RFC1123Z)))
+		return nil
+	})
+}
+
+// renderedHeader renders one of this Email's headers exactly as WriteTo
+// would write it to the wire - same RFC 2047 encoding, same line folding -
+// so that consumers such as the DKIM signer can canonicalize the bytes that
+// actually go out, rather than an approximation of them.
+func (e *Email) renderedHeader(key string) string {
+	buf := &bytes.Buffer{}
+
+	switch key {
+	case "From":
+		writeEscapeAddressHeader(buf, "From", e.From)
+	case "To":
+		if len(e.To) == 0 {
+			return ""
+		}
+		writeEscapeAddressHeader(buf, "To", e.To...)
+	case "Cc":
+		if len(e.Cc) == 0 {
+			return ""
+		}
+		writeEscapeAddressHeader(buf, "Cc", e.Cc...)
+	case "Reply-To":
+		if len(e.ReplyTo) == 0 {
+			return ""
+		}
+		writeEscapeAddressHeader(buf, "Reply-To", e.ReplyTo...)
+	case "Subject":
+		writeEscapeHeader(buf, "Subject", e.Subject)
+	case "Date":
+		writeEscapeHeader(buf, "Date", e.Date.Format(time.RFC1123Z))
+	case "Message-Id":
+		writeEscapeHeader(buf, "Message-Id", e.MessageId)
+	default:
+		if v := e.Headers.Get(key); v != "" {
+			writeEscapeHeader(buf, key, v)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,112 @@
+package email
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestSignPGPWrapsMultipartSigned(t *testing.T) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New("Subject", mail.Address{"Test", "test@example.org"}, mail.Address{"To", "to@example.org"})
+	m.AddTextBodyString("Hello, world!")
+	m.SignPGP(entity)
+
+	b, err := m.Bytes(Mode8Bit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mess, err := mail.ReadMessage(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := mess.Header.Get("Content-Type")
+	if !strings.Contains(ct, "multipart/signed") || !strings.Contains(ct, `protocol="application/pgp-signature"`) {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestEncryptPGPWrapsMultipartEncrypted(t *testing.T) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New("Subject", mail.Address{"Test", "test@example.org"}, mail.Address{"To", "to@example.org"})
+	m.AddTextBodyString("Hello, world!")
+	m.EncryptPGP(openpgp.EntityList{entity})
+
+	b, err := m.Bytes(Mode8Bit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mess, err := mail.ReadMessage(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := mess.Header.Get("Content-Type")
+	if !strings.Contains(ct, "multipart/encrypted") || !strings.Contains(ct, `protocol="application/pgp-encrypted"`) {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	_, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(mess.Body, params["boundary"])
+
+	ctrl, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl.Close()
+
+	encrypted, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := armor.Decode(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatalf("decrypting: %v", err)
+	}
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(plaintext), "Hello, world!") {
+		t.Errorf("decrypted plaintext = %q, want it to contain %q", plaintext, "Hello, world!")
+	}
+}
+
+func TestWriteToWithoutPGPLeavesMessageUnchanged(t *testing.T) {
+	m := New("Subject", mail.Address{"Test", "test@example.org"}, mail.Address{"To", "to@example.org"})
+	m.AddTextBodyString("Hello")
+	m.Date = time.Now()
+
+	if _, ok := m.Message.(*MIMEPartText); !ok {
+		t.Fatalf("expected MIMEPartText before WriteTo, got %#v", m.Message)
+	}
+	if _, err := m.Bytes(Mode8Bit); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Message.(*MIMEPartText); !ok {
+		t.Fatalf("expected WriteTo to leave Message untouched, got %#v", m.Message)
+	}
+}
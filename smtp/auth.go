@@ -0,0 +1,41 @@
+package smtp
+
+import (
+	"errors"
+	"net/smtp"
+)
+
+// loginAuth implements the (undocumented, but widely deployed) LOGIN
+// mechanism, which net/smtp does not support natively.
+type loginAuth struct {
+	username, password string
+	host               string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("email/smtp: unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("email/smtp: wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:":
+		return []byte(a.username), nil
+	case "Password:":
+		return []byte(a.password), nil
+	default:
+		return nil, errors.New("email/smtp: unexpected server challenge")
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
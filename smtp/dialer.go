@@ -0,0 +1,252 @@
+// Package smtp delivers *email.Email messages over SMTP, with support for
+// implicit TLS, opportunistic STARTTLS, authentication, and connection
+// reuse for bulk sends.
+package smtp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/wedogo/email"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// A Sender delivers a single message to an envelope of recipients.
+type Sender interface {
+	Send(from string, to []string, e *email.Email) error
+}
+
+// A SendCloser is a Sender that holds an open connection which must be
+// closed once the caller is done sending messages through it.
+type SendCloser interface {
+	Sender
+	Close() error
+}
+
+// A Dialer dials an SMTP server and delivers messages to it. The zero value
+// is not usable; construct one with NewDialer.
+type Dialer struct {
+	// Host is the SMTP server's host name or IP address.
+	Host string
+	// Port is the SMTP server's port.
+	Port int
+	// Username and Password are used for authentication, if set.
+	Username, Password string
+	// Auth, if set, overrides the automatic authentication mechanism
+	// selection based on the server's EHLO response.
+	Auth smtp.Auth
+	// SSL enables implicit TLS (as used on port 465). When false, the
+	// Dialer still upgrades the connection with STARTTLS if the server
+	// advertises it.
+	SSL bool
+	// TLSConfig is used for both implicit TLS and STARTTLS. It defaults to
+	// a config with ServerName set to Host.
+	TLSConfig *tls.Config
+	// LocalName is used in the EHLO/HELO greeting. It defaults to
+	// "localhost".
+	LocalName string
+	// Timeout is the maximum time to wait for the connection to be
+	// established. It defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+// Create a new Dialer. The Dialer defaults to implicit TLS on port 465 and
+// opportunistic STARTTLS on any other port.
+func NewDialer(host string, port int, username, password string) *Dialer {
+	return &Dialer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		SSL:      port == 465,
+	}
+}
+
+func (d *Dialer) tlsConfig() *tls.Config {
+	if d.TLSConfig != nil {
+		return d.TLSConfig
+	}
+	return &tls.Config{ServerName: d.Host}
+}
+
+// Dial establishes a connection to the SMTP server and returns a SendCloser
+// that can be used to deliver several messages over it. The caller must
+// Close it once done.
+func (d *Dialer) Dial() (SendCloser, error) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	addr := fmt.Sprintf("%s:%d", d.Host, d.Port)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.SSL {
+		conn = tls.Client(conn, d.tlsConfig())
+	}
+
+	c, err := smtp.NewClient(conn, d.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	localName := d.LocalName
+	if localName == "" {
+		localName = "localhost"
+	}
+	if err = c.Hello(localName); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if !d.SSL {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(d.tlsConfig()); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+	}
+
+	auth := d.Auth
+	if auth == nil && d.Username != "" {
+		if ok, params := c.Extension("AUTH"); ok {
+			auth = d.authFor(params)
+		}
+	}
+	if auth != nil {
+		if err = c.Auth(auth); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	// net/smtp has no BDAT/CHUNKING support: Data() always sends the
+	// classic DATA command through a dot-stuffing textproto.Writer, which
+	// rewrites bare "\n" to "\r\n" and would corrupt a binary payload.
+	// Cap negotiated mode at Mode8Bit until BDAT is implemented here.
+	mode := email.Mode7Bit
+	if ok, _ := c.Extension("8BITMIME"); ok {
+		mode = email.Mode8Bit
+	}
+
+	return &client{c: c, mode: mode}, nil
+}
+
+// authFor picks an authentication mechanism from the server's advertised
+// AUTH parameters, preferring CRAM-MD5, then LOGIN, then PLAIN.
+func (d *Dialer) authFor(params string) smtp.Auth {
+	mechanisms := strings.Fields(params)
+	has := func(name string) bool {
+		for _, m := range mechanisms {
+			if strings.EqualFold(m, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("CRAM-MD5"):
+		return smtp.CRAMMD5Auth(d.Username, d.Password)
+	case has("LOGIN"):
+		return &loginAuth{username: d.Username, password: d.Password, host: d.Host}
+	default:
+		return smtp.PlainAuth("", d.Username, d.Password, d.Host)
+	}
+}
+
+// DialAndSend dials the SMTP server, sends the given messages, and closes
+// the connection.
+func (d *Dialer) DialAndSend(m ...*email.Email) error {
+	sc, err := d.Dial()
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	for _, e := range m {
+		if err := Send(sc, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send derives the envelope (MAIL FROM / RCPT TO) from e.From, e.To, e.Cc
+// and e.Bcc and delivers e through s.
+func Send(s Sender, e *email.Email) error {
+	from := e.From.Address
+	to := make([]string, 0, len(e.To)+len(e.Cc)+len(e.Bcc))
+	for _, group := range [][]mail.Address{e.To, e.Cc, e.Bcc} {
+		for _, a := range group {
+			to = append(to, a.Address)
+		}
+	}
+	return s.Send(from, to, e)
+}
+
+// client is a SendCloser backed by a live net/smtp connection.
+type client struct {
+	c    *smtp.Client
+	mode email.Mode
+}
+
+func (cl *client) Send(from string, to []string, e *email.Email) error {
+	// Bcc recipients must receive the message but must not see each
+	// other's addresses on the wire, so strip the header before DATA.
+	wire := *e
+	wire.Bcc = nil
+
+	// Email.Headers is a map, so the struct copy above still shares it with
+	// e. Middlewares run inside Bytes and mutate Headers via SetHeader, so
+	// without a clone, sending e would leak those changes back onto the
+	// caller's original Email.
+	wire.Headers = make(textproto.MIMEHeader, len(e.Headers))
+	for k, v := range e.Headers {
+		wire.Headers[k] = append([]string(nil), v...)
+	}
+
+	raw, err := wire.Bytes(cl.mode)
+	if err != nil {
+		return err
+	}
+
+	if err := cl.c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := cl.c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := cl.c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (cl *client) Close() error {
+	if err := cl.c.Quit(); err != nil {
+		cl.c.Close()
+		return err
+	}
+	return nil
+}
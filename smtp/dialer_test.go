@@ -0,0 +1,110 @@
+package smtp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/wedogo/email"
+)
+
+func TestNewDialerDefaultsSSL(t *testing.T) {
+	d := NewDialer("smtp.example.org", 465, "user", "pass")
+	if !d.SSL {
+		t.Error("expected SSL to default to true on port 465")
+	}
+
+	d = NewDialer("smtp.example.org", 587, "user", "pass")
+	if d.SSL {
+		t.Error("expected SSL to default to false on port 587")
+	}
+}
+
+func TestDialerAuthFor(t *testing.T) {
+	d := NewDialer("smtp.example.org", 587, "user", "pass")
+
+	tests := []struct {
+		params   string
+		wantType string
+	}{
+		{"PLAIN LOGIN CRAM-MD5", "*smtp.cramMD5Auth"},
+		{"PLAIN LOGIN", "*smtp.loginAuth"},
+		{"PLAIN", "*smtp.plainAuth"},
+	}
+
+	for _, test := range tests {
+		auth := d.authFor(test.params)
+		if auth == nil {
+			t.Fatalf("authFor(%q) = nil", test.params)
+		}
+		if got := fmt.Sprintf("%T", auth); got != test.wantType {
+			t.Errorf("authFor(%q) = %s, want %s", test.params, got, test.wantType)
+		}
+	}
+}
+
+// newPipeClient wires a client up to a fake SMTP server, good enough to
+// drive MAIL/RCPT/DATA, over an in-memory net.Pipe.
+func newPipeClient(t *testing.T) *client {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+
+	go func() {
+		defer serverConn.Close()
+		serverConn.Write([]byte("220 fake.example.org ESMTP\r\n"))
+		r := bufio.NewReader(serverConn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "DATA"):
+				serverConn.Write([]byte("354 Go ahead\r\n"))
+				for {
+					dataLine, err := r.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+				}
+				serverConn.Write([]byte("250 OK\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				serverConn.Write([]byte("221 Bye\r\n"))
+				return
+			default:
+				serverConn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	c, err := smtp.NewClient(clientConn, "fake.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{c: c, mode: email.Mode8Bit}
+}
+
+func TestClientSendDoesNotMutateCallerHeaders(t *testing.T) {
+	cl := newPipeClient(t)
+
+	e := email.New("Subject", mail.Address{Name: "From", Address: "from@example.org"}, mail.Address{Name: "To", Address: "to@example.org"})
+	e.AddTextBodyString("hello")
+	// Pre-allocate Headers, as it would be once a header has been set at
+	// least once, so a shared (rather than per-copy) map would be caught.
+	e.AddHeader("X-Original", "value")
+	e.Use(email.ListUnsubscribe("https://example.org/unsub"))
+
+	if err := cl.Send("from@example.org", []string{"to@example.org"}, e); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := e.Headers.Get("List-Unsubscribe"); got != "" {
+		t.Errorf("Send leaked a middleware header onto the caller's Email: List-Unsubscribe = %q, want unset", got)
+	}
+}
@@ -0,0 +1,73 @@
+package email
+
+import (
+	"io"
+	"os"
+)
+
+// MIMEPartFile is a MIME part backed by a file on disk. Unlike
+// MIMEPartBinary, whose Content is a single-use io.Reader, MIMEPartFile
+// opens Path anew on every WriteTo call, so the same *Email can be
+// serialized (and sent through several SMTP connections) more than once
+// without re-buffering its attachments into memory.
+type MIMEPartFile struct {
+	Type        string
+	Disposition string
+	Filename    string
+	ContentID   string
+	Path        string
+}
+
+func (p *MIMEPartFile) WriteTo(w io.Writer, m Mode) error {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	part := &MIMEPartBinary{
+		Type:        p.Type,
+		Disposition: p.Disposition,
+		Filename:    p.Filename,
+		ContentID:   p.ContentID,
+		Content:     f,
+	}
+	return part.WriteTo(w, m)
+}
+
+// SetContent replaces the part's content. As with Content set directly,
+// the reader is consumed once, on the next WriteTo call.
+func (p *MIMEPartBinary) SetContent(r io.Reader) {
+	p.Content = r
+}
+
+// SetContentFile backs the part's content with a file, re-opened on every
+// read pass so a single part can survive being written out more than once.
+func (p *MIMEPartBinary) SetContentFile(path string) {
+	p.Content = &reopeningFileReader{path: path}
+}
+
+// reopeningFileReader opens path lazily on first Read and transparently
+// reopens it after being fully drained, so one instance can back several
+// full WriteTo passes over the same part.
+type reopeningFileReader struct {
+	path string
+	f    *os.File
+}
+
+func (r *reopeningFileReader) Read(p []byte) (int, error) {
+	if r.f == nil {
+		f, err := os.Open(r.path)
+		if err != nil {
+			return 0, err
+		}
+		r.f = f
+	}
+
+	n, err := r.f.Read(p)
+	if err == io.EOF {
+		r.f.Close()
+		r.f = nil
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+)
+
+// zeroReader yields remaining zero bytes without allocating a backing buffer, so
+// benchmarks can exercise gigabyte-scale attachments without materializing
+// them in memory.
+type zeroReader struct {
+	remaining int64
+}
+
+func (r *zeroReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	r.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+func TestReopeningFileReader(t *testing.T) {
+	f, err := os.CreateTemp("", "email-reopen-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	r := &reopeningFileReader{path: f.Name()}
+	for i := 0; i < 2; i++ {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("pass %d: got %q, want %q", i, b, "hello")
+		}
+	}
+}
+
+func TestMIMEPartFileWriteToTwice(t *testing.T) {
+	f, err := os.CreateTemp("", "email-part-file-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("attachment-body"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	part := &MIMEPartFile{
+		Type:        "application/octet-stream",
+		Disposition: "attachment",
+		Filename:    "data.bin",
+		Path:        f.Name(),
+	}
+
+	for i := 0; i < 2; i++ {
+		buf := &bytes.Buffer{}
+		if err := part.WriteTo(buf, ModeBinary); err != nil {
+			t.Fatalf("pass %d: %v", i, err)
+		}
+		if !strings.Contains(buf.String(), "attachment-body") {
+			t.Errorf("pass %d: expected body to contain attachment-body, got %q", i, buf.String())
+		}
+	}
+}
+
+func BenchmarkMIMEPartBinaryLargeAttachment(b *testing.B) {
+	const size = 1 << 30 // 1 GiB
+	b.ReportAllocs()
+	b.SetBytes(size)
+
+	for i := 0; i < b.N; i++ {
+		part := &MIMEPartBinary{
+			Type:        "application/octet-stream",
+			Disposition: "attachment",
+			Filename:    "backup.bin",
+			Headers:     textproto.MIMEHeader{},
+			Content:     &zeroReader{remaining: size},
+		}
+		if err := part.WriteTo(io.Discard, Mode8Bit); err != nil {
+			b.Fatal(err)
+		}
+	}
+}